@@ -0,0 +1,449 @@
+// Package status publishes the operator's detailed operator.status onto the
+// ClusterOperator object that the CVO and cluster admins watch.
+package status
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	configv1client "github.com/openshift/client-go/config/clientset/versioned/typed/config/v1"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourcemerge"
+	"github.com/openshift/library-go/pkg/operator/v1helpers"
+)
+
+var workQueueKey = "instance"
+
+// OperatorStatusProvider is implemented by the operator's own status informer
+// so StatusSyncer can watch it for changes and ask it for the current
+// operator.status at sync time.
+type OperatorStatusProvider interface {
+	Informer() cache.SharedIndexInformer
+	CurrentStatus() (operatorv1.OperatorStatus, error)
+}
+
+// VersionGetter tracks the versions of the operator itself and of everything
+// it operates, so they can be published on the ClusterOperator and watched by
+// the CVO to know when the operator has finished upgrading.
+type VersionGetter interface {
+	// SetVersion records the version of operandName, e.g. "operator" for this
+	// binary or "operand" for the openshift-controller-manager image.
+	SetVersion(operandName, version string)
+	// GetVersions returns the currently known operand -> version map.
+	GetVersions() map[string]string
+	// VersionChangedChannel is closed every time SetVersion changes a version.
+	VersionChangedChannel() <-chan struct{}
+}
+
+// UpgradeableChecker is an additional, operator-specific precondition for a
+// minor-version upgrade. It returns upgradeable=false, with a reason and
+// message, to block upgrades, and upgradeable=true when nothing blocks them;
+// reason and message are ignored when upgradeable is true.
+type UpgradeableChecker func() (upgradeable bool, reason, message string)
+
+// StatusSyncer mirrors the OpenShiftControllerManager operator.status onto the
+// ClusterOperator "openshift-controller-manager" object that the CVO and
+// cluster admins watch.
+type StatusSyncer struct {
+	clusterOperatorName string
+
+	clusterOperatorClient configv1client.ConfigV1Interface
+
+	operatorStatusProvider OperatorStatusProvider
+	versionGetter          VersionGetter
+	relatedObjects         []configv1.ObjectReference
+	upgradeableCheckers    []UpgradeableChecker
+	recorder               events.Recorder
+
+	// queue only ever has one item, but it has nice error handling backoff/retry semantics
+	queue workqueue.RateLimitingInterface
+
+	// inFlight tracks syncs that are currently running so Run can wait for
+	// the last one to finish before shutting the queue down.
+	inFlight sync.WaitGroup
+}
+
+// AddUpgradeableChecker registers an additional precondition that is
+// consulted, alongside the *Upgradeable-suffixed operator conditions, every
+// time the Upgradeable ClusterOperator condition is computed.
+func (c *StatusSyncer) AddUpgradeableChecker(checker UpgradeableChecker) {
+	c.upgradeableCheckers = append(c.upgradeableCheckers, checker)
+}
+
+// NewClusterOperatorStatusController returns a controller that keeps the
+// ClusterOperator named name in sync with the OperatorStatus reported by
+// operatorStatusProvider. versionGetter supplies status.versions and
+// relatedObjects is published verbatim as status.relatedObjects for
+// must-gather and `oc adm must-gather` to key off of.
+func NewClusterOperatorStatusController(
+	name string,
+	relatedObjects []configv1.ObjectReference,
+	clusterOperatorClient configv1client.ConfigV1Interface,
+	operatorStatusProvider OperatorStatusProvider,
+	versionGetter VersionGetter,
+	recorder events.Recorder,
+) *StatusSyncer {
+	c := &StatusSyncer{
+		clusterOperatorName:    name,
+		relatedObjects:         relatedObjects,
+		clusterOperatorClient:  clusterOperatorClient,
+		operatorStatusProvider: operatorStatusProvider,
+		versionGetter:          versionGetter,
+		recorder:               recorder,
+
+		queue: workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "StatusSyncer-"+name),
+	}
+
+	operatorStatusProvider.Informer().AddEventHandler(c.eventHandler())
+
+	return c
+}
+
+// watchVersionChanges requeues a sync every time the VersionGetter reports a
+// new version so status.versions stays current even absent any other change
+// to operator.status. VersionChangedChannel returns a channel that is closed
+// exactly once per change, so it must be re-acquired after every signal.
+func (c *StatusSyncer) watchVersionChanges(ctx context.Context) {
+	if c.versionGetter == nil {
+		return
+	}
+	for {
+		select {
+		case <-c.versionGetter.VersionChangedChannel():
+			c.queue.Add(workQueueKey)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// sync reacts to a change in operator.status by mirroring it onto the
+// ClusterOperator, creating it if necessary.
+func (c *StatusSyncer) sync(ctx context.Context) error {
+	currentDetailedStatus, err := c.operatorStatusProvider.CurrentStatus()
+	if apierrors.IsNotFound(err) {
+		glog.Infof("operator.status not found")
+		return c.clusterOperatorClient.ClusterOperators().Delete(ctx, c.clusterOperatorName, metav1.DeleteOptions{})
+	}
+	if err != nil {
+		return err
+	}
+
+	originalClusterOperatorObj, err := c.clusterOperatorClient.ClusterOperators().Get(ctx, c.clusterOperatorName, metav1.GetOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	if apierrors.IsNotFound(err) {
+		glog.Infof("clusterOperator %q not found", c.clusterOperatorName)
+		originalClusterOperatorObj = &configv1.ClusterOperator{
+			ObjectMeta: metav1.ObjectMeta{Name: c.clusterOperatorName},
+		}
+		originalClusterOperatorObj, err = c.clusterOperatorClient.ClusterOperators().Create(ctx, originalClusterOperatorObj, metav1.CreateOptions{})
+		if apierrors.IsNotFound(err) {
+			// The ClusterOperator CRD itself isn't registered yet, which is
+			// expected early in cluster bring-up. Requeue with only a verbose
+			// log line instead of returning an error, since this is routine
+			// and will resolve on its own.
+			glog.V(4).Infof("clusterOperator %q CRD not registered yet, requeuing", c.clusterOperatorName)
+			c.queue.AddRateLimited(workQueueKey)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	clusterOperatorObj := originalClusterOperatorObj.DeepCopy()
+	clusterOperatorObj.Status.RelatedObjects = c.relatedObjects
+
+	// resourcemerge.SetOperatorStatusCondition only bumps LastTransitionTime
+	// when Status actually flips, so repeated syncs with an unchanged
+	// Degraded/Available/Progressing/Upgradeable status don't churn the
+	// ClusterOperator.
+
+	if c.versionGetter != nil {
+		versions := c.versionGetter.GetVersions()
+		operandVersions := make([]configv1.OperandVersion, 0, len(versions))
+		for operand, version := range versions {
+			operandVersions = append(operandVersions, configv1.OperandVersion{Name: operand, Version: version})
+		}
+		sort.Slice(operandVersions, func(i, j int) bool { return operandVersions[i].Name < operandVersions[j].Name })
+		clusterOperatorObj.Status.Versions = operandVersions
+	}
+
+	if available := v1helpers.FindOperatorCondition(currentDetailedStatus.Conditions, operatorv1.OperatorStatusTypeAvailable); available != nil {
+		resourcemerge.SetOperatorStatusCondition(&clusterOperatorObj.Status.Conditions, toClusterOperatorCondition(*available))
+	}
+
+	var degradedConditions []operatorv1.OperatorCondition
+	for _, condition := range currentDetailedStatus.Conditions {
+		// Degraded is the current API; Failing is accepted from operators that
+		// haven't migrated their OperatorStatusProvider yet.
+		if (strings.HasSuffix(condition.Type, "Degraded") || strings.HasSuffix(condition.Type, "Failing")) && condition.Status == operatorv1.ConditionTrue {
+			degradedConditions = append(degradedConditions, condition)
+		}
+	}
+	resourcemerge.SetOperatorStatusCondition(&clusterOperatorObj.Status.Conditions, degradedClusterOperatorCondition(degradedConditions))
+
+	if progressing := v1helpers.FindOperatorCondition(currentDetailedStatus.Conditions, operatorv1.OperatorStatusTypeProgressing); progressing != nil {
+		resourcemerge.SetOperatorStatusCondition(&clusterOperatorObj.Status.Conditions, toClusterOperatorCondition(*progressing))
+	}
+
+	resourcemerge.SetOperatorStatusCondition(&clusterOperatorObj.Status.Conditions, c.upgradeableCondition(currentDetailedStatus))
+
+	if resourcemerge.ClusterOperatorStatusEqual(originalClusterOperatorObj, clusterOperatorObj) {
+		return nil
+	}
+
+	c.recordConditionTransitions(originalClusterOperatorObj.Status.Conditions, clusterOperatorObj.Status.Conditions)
+
+	glog.V(4).Infof("clusterOperator %q set to %#v", c.clusterOperatorName, clusterOperatorObj.Status)
+	_, err = c.clusterOperatorClient.ClusterOperators().UpdateStatus(ctx, clusterOperatorObj, metav1.UpdateOptions{})
+	return err
+}
+
+func toClusterOperatorCondition(condition operatorv1.OperatorCondition) configv1.ClusterOperatorStatusCondition {
+	return configv1.ClusterOperatorStatusCondition{
+		Type:               configv1.ClusterStatusConditionType(condition.Type),
+		Status:             configv1.ConditionStatus(condition.Status),
+		LastTransitionTime: condition.LastTransitionTime,
+		Reason:             condition.Reason,
+		Message:            condition.Message,
+	}
+}
+
+func degradedClusterOperatorCondition(degradedConditions []operatorv1.OperatorCondition) configv1.ClusterOperatorStatusCondition {
+	cond := configv1.ClusterOperatorStatusCondition{
+		Type:   configv1.OperatorDegraded,
+		Status: configv1.ConditionFalse,
+	}
+	if len(degradedConditions) == 0 {
+		return cond
+	}
+
+	cond.Status = configv1.ConditionTrue
+	var messages []string
+	for _, condition := range degradedConditions {
+		if len(condition.Message) == 0 {
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("%s: %s", condition.Type, condition.Message))
+	}
+	if len(messages) > 0 {
+		cond.Message = joinMessages(messages)
+	}
+	if len(degradedConditions) == 1 {
+		cond.Reason = degradedConditions[0].Type
+	} else {
+		cond.Reason = "MultipleConditionsDegraded"
+	}
+	return cond
+}
+
+// upgradeableCondition aggregates the *Upgradeable-suffixed operator
+// conditions and any registered UpgradeableChecker into a single Upgradeable
+// ClusterOperator condition, defaulting to True when nothing blocks the
+// upgrade.
+func (c *StatusSyncer) upgradeableCondition(currentDetailedStatus operatorv1.OperatorStatus) configv1.ClusterOperatorStatusCondition {
+	var blockingReasons, blockingMessages []string
+
+	for _, condition := range currentDetailedStatus.Conditions {
+		if !strings.HasSuffix(condition.Type, string(operatorv1.OperatorStatusTypeUpgradeable)) || condition.Status != operatorv1.ConditionFalse {
+			continue
+		}
+		reason := condition.Reason
+		if len(reason) == 0 {
+			reason = condition.Type
+		}
+		blockingReasons = append(blockingReasons, reason)
+		if len(condition.Message) > 0 {
+			blockingMessages = append(blockingMessages, fmt.Sprintf("%s: %s", condition.Type, condition.Message))
+		}
+	}
+
+	for _, checker := range c.upgradeableCheckers {
+		upgradeable, reason, message := checker()
+		if upgradeable {
+			continue
+		}
+		blockingReasons = append(blockingReasons, reason)
+		if len(message) > 0 {
+			blockingMessages = append(blockingMessages, message)
+		}
+	}
+
+	if len(blockingReasons) == 0 {
+		return configv1.ClusterOperatorStatusCondition{
+			Type:   configv1.OperatorUpgradeable,
+			Status: configv1.ConditionTrue,
+		}
+	}
+
+	reason := blockingReasons[0]
+	if len(blockingReasons) > 1 {
+		reason = "MultipleConditionsBlockingUpgrade"
+	}
+	return configv1.ClusterOperatorStatusCondition{
+		Type:    configv1.OperatorUpgradeable,
+		Status:  configv1.ConditionFalse,
+		Reason:  reason,
+		Message: joinMessages(blockingMessages),
+	}
+}
+
+func joinMessages(messages []string) string {
+	joined := ""
+	for i, m := range messages {
+		if i > 0 {
+			joined += "\n"
+		}
+		joined += m
+	}
+	return joined
+}
+
+// drainTimeout bounds how long Run waits, after ctx is cancelled, for the
+// workqueue to finish the sync already in flight before it shuts down.
+const drainTimeout = 10 * time.Second
+
+// Run starts the controller and blocks until ctx is cancelled. workers is
+// accepted for symmetry with other controllers, but only one worker is ever
+// started since the workqueue only ever holds a single key.
+func (c *StatusSyncer) Run(ctx context.Context, workers int) {
+	defer utilruntime.HandleCrash()
+
+	glog.Infof("Starting StatusSyncer-" + c.clusterOperatorName)
+	defer glog.Infof("Shutting down StatusSyncer-" + c.clusterOperatorName)
+
+	if !cache.WaitForCacheSync(ctx.Done(), c.operatorStatusProvider.Informer().HasSynced) {
+		utilruntime.HandleError(fmt.Errorf("StatusSyncer-%s: timed out waiting for informer cache to sync", c.clusterOperatorName))
+		return
+	}
+
+	go c.watchVersionChanges(ctx)
+
+	// doesn't matter what workers say, only start one.
+	go wait.UntilWithContext(ctx, c.runWorker, time.Second)
+
+	<-ctx.Done()
+	c.drainAndShutDown()
+}
+
+// drainAndShutDown gives a sync already in flight up to drainTimeout to
+// finish before forcing the workqueue closed, so a shutdown mid-sync doesn't
+// leave the ClusterOperator half-written.
+func (c *StatusSyncer) drainAndShutDown() {
+	defer c.queue.ShutDown()
+
+	done := make(chan struct{})
+	go func() {
+		c.inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(drainTimeout):
+		utilruntime.HandleError(fmt.Errorf("StatusSyncer-%s: timed out waiting for in-flight sync to finish", c.clusterOperatorName))
+	}
+}
+
+func (c *StatusSyncer) runWorker(ctx context.Context) {
+	for c.processNextWorkItem() {
+	}
+}
+
+// processNextWorkItem runs sync with its own short-lived context, independent
+// of Run's ctx, so a sync already in flight when Run's ctx is cancelled gets a
+// chance to finish its writes instead of failing with context.Canceled.
+func (c *StatusSyncer) processNextWorkItem() bool {
+	dsKey, quit := c.queue.Get()
+	if quit {
+		return false
+	}
+	defer c.queue.Done(dsKey)
+
+	c.inFlight.Add(1)
+	defer c.inFlight.Done()
+
+	syncCtx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancel()
+
+	err := c.sync(syncCtx)
+	if err == nil {
+		c.queue.Forget(dsKey)
+		return true
+	}
+
+	utilruntime.HandleError(fmt.Errorf("%v failed with: %v", dsKey, err))
+	c.queue.AddRateLimited(dsKey)
+
+	return true
+}
+
+// recordConditionTransitions emits a Kubernetes event for every Degraded or
+// Progressing status flip between old and new, giving cluster admins an
+// `oc get events` trail for status flapping without having to diff
+// ClusterOperator revisions.
+func (c *StatusSyncer) recordConditionTransitions(old, new []configv1.ClusterOperatorStatusCondition) {
+	if c.recorder == nil {
+		return
+	}
+
+	for _, condition := range new {
+		oldCondition := findClusterOperatorCondition(old, condition.Type)
+		// No prior condition of this type means there is nothing to
+		// transition from (fresh install, or a condition type introduced
+		// after this ClusterOperator was first created) -- nothing to report.
+		if oldCondition == nil || oldCondition.Status == condition.Status {
+			continue
+		}
+
+		switch condition.Type {
+		case configv1.OperatorDegraded:
+			if condition.Status == configv1.ConditionTrue {
+				c.recorder.Warningf("OperatorDegraded", "Degraded: %s", condition.Message)
+			} else {
+				c.recorder.Eventf("OperatorDegradedResolved", "Degraded: %s", condition.Message)
+			}
+		case configv1.OperatorProgressing:
+			if condition.Status == configv1.ConditionTrue {
+				c.recorder.Eventf("OperatorProgressing", "Progressing: %s", condition.Message)
+			} else {
+				c.recorder.Eventf("OperatorProgressingComplete", "Progressing: %s", condition.Message)
+			}
+		}
+	}
+}
+
+func findClusterOperatorCondition(conditions []configv1.ClusterOperatorStatusCondition, conditionType configv1.ClusterStatusConditionType) *configv1.ClusterOperatorStatusCondition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
+
+// eventHandler queues the operator to check spec and status
+func (c *StatusSyncer) eventHandler() cache.ResourceEventHandler {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.queue.Add(workQueueKey) },
+		UpdateFunc: func(old, new interface{}) { c.queue.Add(workQueueKey) },
+		DeleteFunc: func(obj interface{}) { c.queue.Add(workQueueKey) },
+	}
+}
@@ -0,0 +1,417 @@
+package status
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	configv1 "github.com/openshift/api/config/v1"
+	operatorv1 "github.com/openshift/api/operator/v1"
+	configv1fake "github.com/openshift/client-go/config/clientset/versioned/fake"
+	"github.com/openshift/library-go/pkg/operator/events"
+)
+
+// fakeVersionGetter hands out a fresh channel after every change, mirroring
+// the real VersionGetter's close-to-signal contract.
+type fakeVersionGetter struct {
+	mu sync.Mutex
+	ch chan struct{}
+	// acquired counts VersionChangedChannel calls, so a test can wait until
+	// the watcher holds the current channel before signalling a change.
+	acquired int
+}
+
+func newFakeVersionGetter() *fakeVersionGetter {
+	return &fakeVersionGetter{ch: make(chan struct{})}
+}
+
+func (f *fakeVersionGetter) SetVersion(operandName, version string) {}
+func (f *fakeVersionGetter) GetVersions() map[string]string         { return nil }
+
+func (f *fakeVersionGetter) VersionChangedChannel() <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.acquired++
+	return f.ch
+}
+
+func (f *fakeVersionGetter) acquisitions() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.acquired
+}
+
+func (f *fakeVersionGetter) change() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	close(f.ch)
+	f.ch = make(chan struct{})
+}
+
+func TestWatchVersionChanges(t *testing.T) {
+	versionGetter := newFakeVersionGetter()
+	c := &StatusSyncer{
+		versionGetter: versionGetter,
+		queue:         workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "test"),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go c.watchVersionChanges(ctx)
+
+	// A change is only seen on a channel the watcher already holds, so wait
+	// for it to pick one up before signalling.
+	waitForWatcher := func(n int) {
+		if err := wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+			return versionGetter.acquisitions() >= n, nil
+		}); err != nil {
+			t.Fatalf("expected the watcher to acquire version channel %d, it did not: %v", n, err)
+		}
+	}
+
+	waitForWatcher(1)
+	versionGetter.change()
+	if err := wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		return c.queue.Len() > 0, nil
+	}); err != nil {
+		t.Fatalf("expected a resync to be queued after a version change, got none: %v", err)
+	}
+
+	// Drain it and trigger a second change: if watchVersionChanges only ever
+	// consumed the channel once, this would never queue another resync.
+	item, _ := c.queue.Get()
+	c.queue.Done(item)
+	waitForWatcher(2)
+	versionGetter.change()
+	if err := wait.PollImmediate(10*time.Millisecond, time.Second, func() (bool, error) {
+		return c.queue.Len() > 0, nil
+	}); err != nil {
+		t.Fatalf("expected a second resync to be queued after another version change, got none: %v", err)
+	}
+}
+
+func TestDegradedClusterOperatorCondition(t *testing.T) {
+	tests := []struct {
+		name           string
+		degraded       []operatorv1.OperatorCondition
+		expectedStatus configv1.ConditionStatus
+		expectedReason string
+		expectedMsg    string
+	}{
+		{
+			name:           "no degraded conditions",
+			degraded:       nil,
+			expectedStatus: configv1.ConditionFalse,
+		},
+		{
+			name: "single Degraded condition",
+			degraded: []operatorv1.OperatorCondition{
+				{Type: "FooDegraded", Status: operatorv1.ConditionTrue, Message: "something broke"},
+			},
+			expectedStatus: configv1.ConditionTrue,
+			expectedReason: "FooDegraded",
+			expectedMsg:    "FooDegraded: something broke",
+		},
+		{
+			name: "single legacy Failing condition",
+			degraded: []operatorv1.OperatorCondition{
+				{Type: "FooFailing", Status: operatorv1.ConditionTrue, Message: "something broke"},
+			},
+			expectedStatus: configv1.ConditionTrue,
+			expectedReason: "FooFailing",
+			expectedMsg:    "FooFailing: something broke",
+		},
+		{
+			name: "multiple degraded conditions",
+			degraded: []operatorv1.OperatorCondition{
+				{Type: "FooDegraded", Status: operatorv1.ConditionTrue, Message: "foo broke"},
+				{Type: "BarFailing", Status: operatorv1.ConditionTrue, Message: "bar broke"},
+			},
+			expectedStatus: configv1.ConditionTrue,
+			expectedReason: "MultipleConditionsDegraded",
+			expectedMsg:    "FooDegraded: foo broke\nBarFailing: bar broke",
+		},
+		{
+			name: "degraded condition with no message",
+			degraded: []operatorv1.OperatorCondition{
+				{Type: "FooDegraded", Status: operatorv1.ConditionTrue},
+			},
+			expectedStatus: configv1.ConditionTrue,
+			expectedReason: "FooDegraded",
+			expectedMsg:    "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cond := degradedClusterOperatorCondition(test.degraded)
+			if cond.Type != configv1.OperatorDegraded {
+				t.Errorf("expected type %q, got %q", configv1.OperatorDegraded, cond.Type)
+			}
+			if cond.Status != test.expectedStatus {
+				t.Errorf("expected status %q, got %q", test.expectedStatus, cond.Status)
+			}
+			if cond.Reason != test.expectedReason {
+				t.Errorf("expected reason %q, got %q", test.expectedReason, cond.Reason)
+			}
+			if cond.Message != test.expectedMsg {
+				t.Errorf("expected message %q, got %q", test.expectedMsg, cond.Message)
+			}
+		})
+	}
+}
+
+func TestUpgradeableCondition(t *testing.T) {
+	tests := []struct {
+		name           string
+		conditions     []operatorv1.OperatorCondition
+		checkers       []UpgradeableChecker
+		expectedStatus configv1.ConditionStatus
+		expectedReason string
+		expectedMsg    string
+	}{
+		{
+			name:           "no blockers",
+			expectedStatus: configv1.ConditionTrue,
+		},
+		{
+			name: "single blocking operator condition with reason and message",
+			conditions: []operatorv1.OperatorCondition{
+				{Type: "FooUpgradeable", Status: operatorv1.ConditionFalse, Reason: "FooBlocked", Message: "foo blocks upgrades"},
+			},
+			expectedStatus: configv1.ConditionFalse,
+			expectedReason: "FooBlocked",
+			expectedMsg:    "FooUpgradeable: foo blocks upgrades",
+		},
+		{
+			name: "blocking operator condition with no reason or message falls back to condition type",
+			conditions: []operatorv1.OperatorCondition{
+				{Type: "FooUpgradeable", Status: operatorv1.ConditionFalse},
+			},
+			expectedStatus: configv1.ConditionFalse,
+			expectedReason: "FooUpgradeable",
+			expectedMsg:    "",
+		},
+		{
+			name: "blocking UpgradeableChecker",
+			checkers: []UpgradeableChecker{
+				func() (bool, string, string) { return false, "UnsupportedManagementState", "managementState is Unmanaged" },
+			},
+			expectedStatus: configv1.ConditionFalse,
+			expectedReason: "UnsupportedManagementState",
+			expectedMsg:    "managementState is Unmanaged",
+		},
+		{
+			name: "non-blocking UpgradeableChecker is ignored",
+			checkers: []UpgradeableChecker{
+				func() (bool, string, string) { return true, "", "" },
+			},
+			expectedStatus: configv1.ConditionTrue,
+		},
+		{
+			name: "multiple blockers",
+			conditions: []operatorv1.OperatorCondition{
+				{Type: "FooUpgradeable", Status: operatorv1.ConditionFalse, Reason: "FooBlocked", Message: "foo blocks upgrades"},
+			},
+			checkers: []UpgradeableChecker{
+				func() (bool, string, string) { return false, "UnsupportedManagementState", "managementState is Unmanaged" },
+			},
+			expectedStatus: configv1.ConditionFalse,
+			expectedReason: "MultipleConditionsBlockingUpgrade",
+			expectedMsg:    "FooUpgradeable: foo blocks upgrades\nmanagementState is Unmanaged",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &StatusSyncer{upgradeableCheckers: test.checkers}
+			cond := c.upgradeableCondition(operatorv1.OperatorStatus{Conditions: test.conditions})
+			if cond.Type != configv1.OperatorUpgradeable {
+				t.Errorf("expected type %q, got %q", configv1.OperatorUpgradeable, cond.Type)
+			}
+			if cond.Status != test.expectedStatus {
+				t.Errorf("expected status %q, got %q", test.expectedStatus, cond.Status)
+			}
+			if cond.Reason != test.expectedReason {
+				t.Errorf("expected reason %q, got %q", test.expectedReason, cond.Reason)
+			}
+			if cond.Message != test.expectedMsg {
+				t.Errorf("expected message %q, got %q", test.expectedMsg, cond.Message)
+			}
+		})
+	}
+}
+
+func TestRecordConditionTransitions(t *testing.T) {
+	tests := []struct {
+		name          string
+		old           []configv1.ClusterOperatorStatusCondition
+		new           []configv1.ClusterOperatorStatusCondition
+		expectReasons []string
+	}{
+		{
+			name: "no prior condition of this type records nothing",
+			old:  nil,
+			new: []configv1.ClusterOperatorStatusCondition{
+				{Type: configv1.OperatorDegraded, Status: configv1.ConditionFalse},
+				{Type: configv1.OperatorProgressing, Status: configv1.ConditionFalse},
+			},
+		},
+		{
+			name: "unchanged status records nothing",
+			old: []configv1.ClusterOperatorStatusCondition{
+				{Type: configv1.OperatorDegraded, Status: configv1.ConditionFalse},
+			},
+			new: []configv1.ClusterOperatorStatusCondition{
+				{Type: configv1.OperatorDegraded, Status: configv1.ConditionFalse},
+			},
+		},
+		{
+			name: "degraded becomes true",
+			old: []configv1.ClusterOperatorStatusCondition{
+				{Type: configv1.OperatorDegraded, Status: configv1.ConditionFalse},
+			},
+			new: []configv1.ClusterOperatorStatusCondition{
+				{Type: configv1.OperatorDegraded, Status: configv1.ConditionTrue},
+			},
+			expectReasons: []string{"OperatorDegraded"},
+		},
+		{
+			name: "degraded resolves",
+			old: []configv1.ClusterOperatorStatusCondition{
+				{Type: configv1.OperatorDegraded, Status: configv1.ConditionTrue},
+			},
+			new: []configv1.ClusterOperatorStatusCondition{
+				{Type: configv1.OperatorDegraded, Status: configv1.ConditionFalse},
+			},
+			expectReasons: []string{"OperatorDegradedResolved"},
+		},
+		{
+			name: "progressing starts",
+			old: []configv1.ClusterOperatorStatusCondition{
+				{Type: configv1.OperatorProgressing, Status: configv1.ConditionFalse},
+			},
+			new: []configv1.ClusterOperatorStatusCondition{
+				{Type: configv1.OperatorProgressing, Status: configv1.ConditionTrue},
+			},
+			expectReasons: []string{"OperatorProgressing"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := events.NewInMemoryRecorder("status-controller-test")
+			c := &StatusSyncer{recorder: recorder}
+
+			c.recordConditionTransitions(test.old, test.new)
+
+			recorded := recorder.Events()
+			if len(recorded) != len(test.expectReasons) {
+				t.Fatalf("expected %d events, got %d: %#v", len(test.expectReasons), len(recorded), recorded)
+			}
+			for i, reason := range test.expectReasons {
+				if recorded[i].Reason != reason {
+					t.Errorf("expected event %d reason %q, got %q", i, reason, recorded[i].Reason)
+				}
+			}
+		})
+	}
+}
+// fakeOperatorStatusProvider reports a fixed operator.status for sync() tests.
+type fakeOperatorStatusProvider struct {
+	status operatorv1.OperatorStatus
+	err    error
+}
+
+func (f *fakeOperatorStatusProvider) Informer() cache.SharedIndexInformer { return nil }
+
+func (f *fakeOperatorStatusProvider) CurrentStatus() (operatorv1.OperatorStatus, error) {
+	return f.status, f.err
+}
+
+func availableOperatorStatus() operatorv1.OperatorStatus {
+	return operatorv1.OperatorStatus{
+		Conditions: []operatorv1.OperatorCondition{
+			{Type: operatorv1.OperatorStatusTypeAvailable, Status: operatorv1.ConditionTrue},
+		},
+	}
+}
+
+func TestSync(t *testing.T) {
+	const name = "test-operator"
+
+	t.Run("creates the ClusterOperator on first sync", func(t *testing.T) {
+		client := configv1fake.NewSimpleClientset()
+		c := &StatusSyncer{
+			clusterOperatorName:    name,
+			clusterOperatorClient:  client.ConfigV1(),
+			operatorStatusProvider: &fakeOperatorStatusProvider{status: availableOperatorStatus()},
+		}
+
+		if err := c.sync(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		co, err := client.ConfigV1().ClusterOperators().Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("expected ClusterOperator to have been created: %v", err)
+		}
+		available := findClusterOperatorCondition(co.Status.Conditions, configv1.OperatorAvailable)
+		if available == nil || available.Status != configv1.ConditionTrue {
+			t.Fatalf("expected Available=True condition, got %#v", co.Status.Conditions)
+		}
+	})
+
+	t.Run("is a no-op once the ClusterOperator already matches", func(t *testing.T) {
+		client := configv1fake.NewSimpleClientset()
+		c := &StatusSyncer{
+			clusterOperatorName:    name,
+			clusterOperatorClient:  client.ConfigV1(),
+			operatorStatusProvider: &fakeOperatorStatusProvider{status: availableOperatorStatus()},
+		}
+
+		// First sync creates it; reset recorded actions so the assertion
+		// below only looks at the second, steady-state sync.
+		if err := c.sync(context.Background()); err != nil {
+			t.Fatalf("unexpected error on first sync: %v", err)
+		}
+		client.Fake.ClearActions()
+
+		if err := c.sync(context.Background()); err != nil {
+			t.Fatalf("unexpected error on second sync: %v", err)
+		}
+
+		for _, action := range client.Fake.Actions() {
+			if action.GetVerb() == "update" {
+				t.Fatalf("expected no update when status is unchanged, got %#v", action)
+			}
+		}
+	})
+
+	t.Run("deletes the ClusterOperator when operator.status is gone", func(t *testing.T) {
+		existing := &configv1.ClusterOperator{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		client := configv1fake.NewSimpleClientset(existing)
+		c := &StatusSyncer{
+			clusterOperatorName:   name,
+			clusterOperatorClient: client.ConfigV1(),
+			operatorStatusProvider: &fakeOperatorStatusProvider{
+				err: apierrors.NewNotFound(operatorv1.Resource("operatorstatus"), name),
+			},
+		}
+
+		if err := c.sync(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := client.ConfigV1().ClusterOperators().Get(context.Background(), name, metav1.GetOptions{}); !apierrors.IsNotFound(err) {
+			t.Fatalf("expected ClusterOperator to have been deleted, got err=%v", err)
+		}
+	})
+}